@@ -0,0 +1,95 @@
+package command
+
+import (
+	"github.com/hashicorp/consul/api"
+)
+
+// consulBackend implements KVBackend against a Consul HTTP API client.
+type consulBackend struct {
+	client       *api.Client
+	queryOptions *api.QueryOptions
+	writeOptions *api.WriteOptions
+}
+
+func newConsulBackend(consul *consulFlags) (*consulBackend, error) {
+	client, err := consul.client()
+	if err != nil {
+		return nil, err
+	}
+	return &consulBackend{
+		client:       client,
+		queryOptions: consul.queryOptions(),
+		writeOptions: consul.writeOptions(),
+	}, nil
+}
+
+func (b *consulBackend) List(prefix string) ([]Pair, error) {
+	kvPairs, _, err := b.client.KV().List(prefix, b.queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]Pair, len(kvPairs))
+	for i, kvPair := range kvPairs {
+		pairs[i] = Pair{Key: kvPair.Key, Value: kvPair.Value, Index: kvPair.ModifyIndex}
+	}
+	return pairs, nil
+}
+
+func (b *consulBackend) Put(pair Pair) error {
+	_, err := b.client.KV().Put(&api.KVPair{Key: pair.Key, Value: pair.Value}, b.writeOptions)
+	return err
+}
+
+func (b *consulBackend) Delete(key string) error {
+	_, err := b.client.KV().Delete(key, b.writeOptions)
+	return err
+}
+
+// Txn submits ops to /v1/txn in batches of at most maxTxnOps, aggregating
+// the keys and reasons from every batch Consul rejects into a
+// TxnFailedKeysError.
+func (b *consulBackend) Txn(ops []TxnOp) error {
+	var failedKeys []string
+	var failedReasons []string
+	for _, batch := range batchOps(ops, maxTxnOps) {
+		txnOps := make(api.TxnOps, len(batch))
+		for i, op := range batch {
+			txnOps[i] = &api.TxnOp{KV: &api.KVTxnOp{
+				Verb:  consulTxnVerb(op.Verb),
+				Key:   op.Key,
+				Value: op.Value,
+				Index: op.Index,
+			}}
+		}
+
+		_, resp, _, err := b.client.Txn().Txn(txnOps, b.queryOptions)
+		if err != nil {
+			return err
+		}
+
+		for _, txnErr := range resp.Errors {
+			if txnErr.OpIndex < len(txnOps) && txnOps[txnErr.OpIndex].KV != nil {
+				failedKeys = append(failedKeys, txnOps[txnErr.OpIndex].KV.Key)
+				failedReasons = append(failedReasons, txnErr.What)
+			}
+		}
+	}
+
+	if len(failedKeys) > 0 {
+		return &TxnFailedKeysError{Keys: failedKeys, Reasons: failedReasons}
+	}
+	return nil
+}
+
+func consulTxnVerb(verb TxnVerb) api.KVOp {
+	switch verb {
+	case TxnDelete:
+		return api.KVDelete
+	case TxnCAS:
+		return api.KVCAS
+	case TxnDeleteCAS:
+		return api.KVDeleteCAS
+	default:
+		return api.KVSet
+	}
+}
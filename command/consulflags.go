@@ -0,0 +1,100 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// envDefault returns the value of the given environment variable, or def if
+// it is unset, mirroring the CONSUL_HTTP_* fallbacks used by the official
+// consul CLI.
+func envDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// consulFlags holds the Consul connection flags shared by every command
+// that talks to the Consul HTTP API (import, sync, export).
+type consulFlags struct {
+	token         *string
+	datacenter    *string
+	httpAddr      *string
+	caFile        *string
+	clientCert    *string
+	clientKey     *string
+	tlsServerName *string
+	relayFactor   *uint
+}
+
+// register adds the Consul connection flags to the given flag set.
+func (f *consulFlags) register(flags *flag.FlagSet) {
+	f.token = flags.String("token", envDefault("CONSUL_HTTP_TOKEN", ""), "ACL token to use. Defaults to CONSUL_HTTP_TOKEN, if set.")
+	f.datacenter = flags.String("datacenter", envDefault("CONSUL_HTTP_DATACENTER", ""), "Datacenter to use. Defaults to CONSUL_HTTP_DATACENTER, if set, or the agent's default.")
+	f.httpAddr = flags.String("http-addr", envDefault("CONSUL_HTTP_ADDR", ""), "HTTP address of the Consul agent. Defaults to CONSUL_HTTP_ADDR, if set.")
+	f.caFile = flags.String("ca-file", envDefault("CONSUL_CACERT", ""), "Path to a CA file to use for TLS verification. Defaults to CONSUL_CACERT, if set.")
+	f.clientCert = flags.String("client-cert", envDefault("CONSUL_CLIENT_CERT", ""), "Path to a client cert file to use for TLS. Defaults to CONSUL_CLIENT_CERT, if set.")
+	f.clientKey = flags.String("client-key", envDefault("CONSUL_CLIENT_KEY", ""), "Path to a client key file to use for TLS. Defaults to CONSUL_CLIENT_KEY, if set.")
+	f.tlsServerName = flags.String("tls-server-name", envDefault("CONSUL_TLS_SERVER_NAME", ""), "Name to use as the SNI host when connecting via TLS. Defaults to CONSUL_TLS_SERVER_NAME, if set.")
+	f.relayFactor = flags.Uint("relay-factor", 0, "Relay factor to use for KV operations, between 0 and 5")
+}
+
+// validate checks the parsed flags for values the flag package itself can't
+// reject, such as -relay-factor being outside the range Consul accepts.
+func (f *consulFlags) validate() error {
+	if *f.relayFactor > 5 {
+		return fmt.Errorf("-relay-factor must be between 0 and 5, got %d", *f.relayFactor)
+	}
+	return nil
+}
+
+// client builds a Consul API client from the parsed flags.
+func (f *consulFlags) client() (*api.Client, error) {
+	config := api.DefaultConfig()
+	if *f.httpAddr != "" {
+		config.Address = *f.httpAddr
+	}
+	if *f.token != "" {
+		config.Token = *f.token
+	}
+	if *f.datacenter != "" {
+		config.Datacenter = *f.datacenter
+	}
+	if *f.caFile != "" {
+		config.TLSConfig.CAFile = *f.caFile
+	}
+	if *f.clientCert != "" {
+		config.TLSConfig.CertFile = *f.clientCert
+	}
+	if *f.clientKey != "" {
+		config.TLSConfig.KeyFile = *f.clientKey
+	}
+	if *f.tlsServerName != "" {
+		config.TLSConfig.Address = *f.tlsServerName
+	}
+	return api.NewClient(config)
+}
+
+// queryOptions builds the QueryOptions shared by all read-only KV calls,
+// carrying the ACL token, datacenter, and relay-factor flags.
+func (f *consulFlags) queryOptions() *api.QueryOptions {
+	return &api.QueryOptions{
+		Token:       *f.token,
+		Datacenter:  *f.datacenter,
+		RelayFactor: uint8(*f.relayFactor),
+	}
+}
+
+// writeOptions builds the WriteOptions for single-key KV calls, carrying the
+// same ACL token, datacenter, and relay-factor flags as queryOptions.
+func (f *consulFlags) writeOptions() *api.WriteOptions {
+	return &api.WriteOptions{
+		Token:       *f.token,
+		Datacenter:  *f.datacenter,
+		RelayFactor: uint8(*f.relayFactor),
+	}
+}
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// diffReport captures the result of diffing local data against the remote
+// KV tree, independent of how it is rendered (-output=text|json|diff).
+type diffReport struct {
+	Inserts   []string     `json:"inserts"`
+	Updates   []diffUpdate `json:"updates"`
+	Deletes   []string     `json:"deletes"`
+	Unchanged int          `json:"unchanged"`
+
+	// insertValues and deleteValues carry the values behind Inserts/Deletes
+	// for -output=diff rendering. They are unexported so they never leak
+	// into the -output=json report.
+	insertValues map[string]string
+	deleteValues map[string]string
+}
+
+type diffUpdate struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// hasChanges reports whether applying the diff would modify the KV backend.
+func (r *diffReport) hasChanges() bool {
+	return len(r.Inserts) > 0 || len(r.Updates) > 0 || len(r.Deletes) > 0
+}
+
+// unifiedDiff renders a single unified-diff hunk for one changed key. Values
+// are diffed line by line when they are valid UTF-8 text, and hex-dumped
+// otherwise.
+func unifiedDiff(key, oldValue, newValue string) string {
+	oldLines, newLines := diffableLines(oldValue), diffableLines(newValue)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s (old)\n", key)
+	fmt.Fprintf(&buf, "+++ %s (new)\n", key)
+	fmt.Fprintf(&buf, "@@ -1,%d +1,%d @@\n", len(oldLines), len(newLines))
+	for _, op := range diffLines(oldLines, newLines) {
+		buf.WriteByte(op.tag)
+		buf.WriteString(op.line)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// diffableLines splits a value into the lines unifiedDiff compares, hex
+// dumping it first if it is not valid UTF-8 text.
+func diffableLines(value string) []string {
+	if value == "" {
+		return nil
+	}
+	if !utf8.ValidString(value) {
+		return hexDumpLines([]byte(value))
+	}
+	return strings.Split(strings.TrimSuffix(value, "\n"), "\n")
+}
+
+// hexDumpLines renders b as classic 16-byte-per-row hex dump lines.
+func hexDumpLines(b []byte) []string {
+	const width = 16
+	lines := make([]string, 0, (len(b)+width-1)/width)
+	for offset := 0; offset < len(b); offset += width {
+		end := offset + width
+		if end > len(b) {
+			end = len(b)
+		}
+		lines = append(lines, fmt.Sprintf("%08x  %s", offset, hex.EncodeToString(b[offset:end])))
+	}
+	return lines
+}
+
+// diffOp is one line of a unified diff: unchanged (' '), removed ('-'), or
+// added ('+').
+type diffOp struct {
+	tag  byte
+	line string
+}
+
+// diffLines computes a minimal line-level diff between a and b using a
+// classic LCS table.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				dp[i][j] = dp[i+1][j+1] + 1
+			case dp[i+1][j] >= dp[i][j+1]:
+				dp[i][j] = dp[i+1][j]
+			default:
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
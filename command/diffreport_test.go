@@ -0,0 +1,52 @@
+package command
+
+import "testing"
+
+func TestDiffLines(t *testing.T) {
+	render := func(ops []diffOp) string {
+		var out string
+		for _, op := range ops {
+			out += string(op.tag) + op.line + "\n"
+		}
+		return out
+	}
+
+	cases := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, " a\n b\n"},
+		{"insert in the middle", []string{"a", "c"}, []string{"a", "b", "c"}, " a\n+b\n c\n"},
+		{"delete in the middle", []string{"a", "b", "c"}, []string{"a", "c"}, " a\n-b\n c\n"},
+		{"all removed", []string{"a", "b"}, nil, "-a\n-b\n"},
+		{"all added", nil, []string{"a", "b"}, "+a\n+b\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := render(diffLines(tc.a, tc.b))
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Run("hunk header counts the old and new line counts", func(t *testing.T) {
+		out := unifiedDiff("key", "a\nb", "a\nc")
+		want := "--- key (old)\n+++ key (new)\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"
+		if out != want {
+			t.Errorf("got %q, want %q", out, want)
+		}
+	})
+
+	t.Run("non-UTF8 values are hex dumped instead of diffed as text", func(t *testing.T) {
+		out := unifiedDiff("key", string([]byte{0xff, 0xfe}), "")
+		want := "--- key (old)\n+++ key (new)\n@@ -1,1 +1,0 @@\n-00000000  fffe\n"
+		if out != want {
+			t.Errorf("got %q, want %q", out, want)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdRequestTimeout bounds every individual call made against etcd.
+const etcdRequestTimeout = 10 * time.Second
+
+// etcdFlags holds the etcd connection flags, the etcd counterpart of
+// consulFlags.
+type etcdFlags struct {
+	endpoints *string
+	username  *string
+	password  *string
+	certFile  *string
+	keyFile   *string
+	caFile    *string
+}
+
+// register adds the etcd connection flags to the given flag set.
+func (f *etcdFlags) register(flags *flag.FlagSet) {
+	f.endpoints = flags.String("etcd-endpoints", envDefault("ETCDCTL_ENDPOINTS", "127.0.0.1:2379"), "Comma-separated list of etcd endpoints")
+	f.username = flags.String("etcd-user", envDefault("ETCDCTL_USER", ""), "etcd username")
+	f.password = flags.String("etcd-password", envDefault("ETCDCTL_PASSWORD", ""), "etcd password")
+	f.certFile = flags.String("etcd-cert", envDefault("ETCDCTL_CERT", ""), "Path to an etcd client cert file for TLS")
+	f.keyFile = flags.String("etcd-key", envDefault("ETCDCTL_KEY", ""), "Path to an etcd client key file for TLS")
+	f.caFile = flags.String("etcd-ca-file", envDefault("ETCDCTL_CACERT", ""), "Path to an etcd CA file for TLS verification")
+}
+
+// client builds an etcd v3 client from the parsed flags.
+func (f *etcdFlags) client() (*clientv3.Client, error) {
+	config := clientv3.Config{
+		Endpoints:   strings.Split(*f.endpoints, ","),
+		DialTimeout: 5 * time.Second,
+		Username:    *f.username,
+		Password:    *f.password,
+	}
+	if *f.certFile != "" || *f.keyFile != "" || *f.caFile != "" {
+		tlsConfig, err := (&transport.TLSInfo{
+			CertFile:      *f.certFile,
+			KeyFile:       *f.keyFile,
+			TrustedCAFile: *f.caFile,
+		}).ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		config.TLS = tlsConfig
+	}
+	return clientv3.New(config)
+}
+
+// etcdBackend implements KVBackend against an etcd v3 cluster.
+type etcdBackend struct {
+	client *clientv3.Client
+}
+
+func newEtcdBackend(etcd *etcdFlags) (*etcdBackend, error) {
+	client, err := etcd.client()
+	if err != nil {
+		return nil, err
+	}
+	return &etcdBackend{client: client}, nil
+}
+
+func (b *etcdBackend) List(prefix string) ([]Pair, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	resp, err := b.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]Pair, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		pairs[i] = Pair{Key: string(kv.Key), Value: kv.Value, Index: uint64(kv.ModRevision)}
+	}
+	return pairs, nil
+}
+
+func (b *etcdBackend) Put(pair Pair) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+	_, err := b.client.Put(ctx, pair.Key, string(pair.Value))
+	return err
+}
+
+func (b *etcdBackend) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+	_, err := b.client.Delete(ctx, key)
+	return err
+}
+
+// Txn applies ops atomically in batches of at most maxTxnOps. Unlike Consul,
+// etcd's Txn only reports whether the whole batch's comparisons held, not
+// which one failed, so on failure every key in the offending batch is
+// reported as a possible conflict rather than the exact offending key.
+func (b *etcdBackend) Txn(ops []TxnOp) error {
+	var failedKeys []string
+	for _, batch := range batchOps(ops, maxTxnOps) {
+		var cmps []clientv3.Cmp
+		var thens []clientv3.Op
+		var keys []string
+		for _, op := range batch {
+			keys = append(keys, op.Key)
+			switch op.Verb {
+			case TxnDelete:
+				thens = append(thens, clientv3.OpDelete(op.Key))
+			case TxnCAS:
+				cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(op.Key), "=", int64(op.Index)))
+				thens = append(thens, clientv3.OpPut(op.Key, string(op.Value)))
+			case TxnDeleteCAS:
+				cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(op.Key), "=", int64(op.Index)))
+				thens = append(thens, clientv3.OpDelete(op.Key))
+			default:
+				thens = append(thens, clientv3.OpPut(op.Key, string(op.Value)))
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+		resp, err := b.client.Txn(ctx).If(cmps...).Then(thens...).Commit()
+		cancel()
+		if err != nil {
+			return err
+		}
+		if !resp.Succeeded {
+			failedKeys = append(failedKeys, keys...)
+		}
+	}
+
+	if len(failedKeys) > 0 {
+		return &TxnFailedKeysError{Keys: failedKeys}
+	}
+	return nil
+}
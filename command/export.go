@@ -0,0 +1,245 @@
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type ExportCommand struct {
+	Ui          cli.Ui
+	name        string
+	args        string
+	synopsis    string
+	flags       *flag.FlagSet
+	exportYAML  *bool
+	exportJSON  *bool
+	exportTAR   *bool
+	arrayGlue   *string
+	splitArrays *bool
+	keyPrefix   *string
+	consul      consulFlags
+	initialised bool
+}
+
+func (c *ExportCommand) init() {
+	if c.initialised {
+		return
+	}
+	c.name = "consulator export"
+	c.args = "[options]"
+	c.synopsis = "Exports data from consul"
+	c.flags = flag.NewFlagSet("export", flag.ContinueOnError)
+	c.exportYAML = c.flags.Bool("yaml", false, "Write the export as YAML")
+	c.exportJSON = c.flags.Bool("json", false, "Write the export as JSON")
+	c.exportTAR = c.flags.Bool("tar", false, "Write the export as a tarball")
+	c.arrayGlue = c.flags.String("glue", "\n", "Glue that was used for joining array values")
+	c.splitArrays = c.flags.Bool("split-arrays", false, "Split scalar values containing -glue back into a sequence. Only safe if every exported value that isn't meant to stay a multi-line scalar was joined with -glue on import")
+	c.keyPrefix = c.flags.String("prefix", "", "Consul tree to export")
+	c.consul.register(c.flags)
+	c.flags.Usage = func() { c.Ui.Output(c.Help()) }
+	c.initialised = true
+}
+
+func (c *ExportCommand) Run(args []string) int {
+	c.init()
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if countTrue(*c.exportYAML, *c.exportJSON, *c.exportTAR) > 1 {
+		c.Ui.Error("Only one output format may be specified")
+		return 1
+	}
+	if err := c.consul.validate(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		return 1
+	}
+	// clean up the prefix
+	*c.keyPrefix = strings.TrimSuffix(strings.TrimSpace(*c.keyPrefix), "/")
+	if *c.keyPrefix != "" {
+		*c.keyPrefix = *c.keyPrefix + "/"
+	}
+
+	data, err := c.listConsul()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		return 1
+	}
+
+	tree, err := c.toTree(data)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		return 1
+	}
+
+	var out []byte
+	switch {
+	case *c.exportJSON:
+		out, err = json.MarshalIndent(tree, "", "  ")
+	case *c.exportTAR:
+		out, err = c.encodeAsTAR(data)
+	default:
+		out, err = yaml.Marshal(tree)
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(string(out))
+	return 0
+}
+
+// listConsul fetches every key under the configured prefix and strips the
+// prefix back off, mirroring the relative keys configparser.Parse produces.
+func (c *ExportCommand) listConsul() (map[string][]byte, error) {
+	client, err := c.consul.client()
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, _, err := client.KV().List(*c.keyPrefix, c.consul.queryOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(pairs))
+	for _, pair := range pairs {
+		relativeKey := strings.TrimPrefix(pair.Key, *c.keyPrefix)
+		if relativeKey == "" {
+			continue
+		}
+		data[relativeKey] = pair.Value
+	}
+	return data, nil
+}
+
+// encodeAsTAR writes one tar entry per key, mirroring configparser.ParseAsTAR
+// which treats each file in the archive as a key relative to its path.
+func (c *ExportCommand) encodeAsTAR(data map[string][]byte) ([]byte, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	for _, key := range keys {
+		val := data[key]
+		if err := w.WriteHeader(&tar.Header{
+			Name: key,
+			Mode: 0644,
+			Size: int64(len(val)),
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(val); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toTree reconstructs a nested map[string]interface{} by splitting each flat
+// key on "/", reversing the flattening configparser.Parse performs on
+// YAML/JSON documents. With -split-arrays, values containing the glue
+// string are split back into a sequence, undoing array joining.
+//
+// Consul's KV store has no notion of directories, so a non-hierarchical
+// layout like keys "a" and "a/b" both existing is perfectly legal. That
+// can't be represented as a tree, since "a" would need to be both a scalar
+// and a map at once: toTree reports it as an error rather than silently
+// dropping whichever value loses the collision.
+func (c *ExportCommand) toTree(data map[string][]byte) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tree := make(map[string]interface{})
+	for _, key := range keys {
+		segments := strings.Split(key, "/")
+		node := tree
+		for i, segment := range segments[:len(segments)-1] {
+			existing, present := node[segment]
+			if !present {
+				child := make(map[string]interface{})
+				node[segment] = child
+				node = child
+				continue
+			}
+			child, ok := existing.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %q conflicts with scalar key %q", key, strings.Join(segments[:i+1], "/"))
+			}
+			node = child
+		}
+
+		leaf := segments[len(segments)-1]
+		if existing, present := node[leaf]; present {
+			if _, ok := existing.(map[string]interface{}); ok {
+				return nil, fmt.Errorf("key %q conflicts with a nested key under the same path", key)
+			}
+		}
+		node[leaf] = c.toValue(data[key])
+	}
+	return tree, nil
+}
+
+// toValue splits a scalar back into a sequence when -split-arrays is set and
+// it contains the glue string, otherwise leaves it as a plain string.
+//
+// Consul stores every value as an opaque byte string, so there is no signal
+// on export distinguishing a joined array from an ordinary multi-line
+// scalar (a PEM blob, a script, a JSON document) that merely happens to
+// contain the glue. Splitting by default would silently corrupt the latter,
+// so reconstruction is opt-in and the operator is trusted to only enable it
+// for trees where every value was in fact joined with -glue on import.
+func (c *ExportCommand) toValue(val []byte) interface{} {
+	str := string(val)
+	if !*c.splitArrays || *c.arrayGlue == "" || !strings.Contains(str, *c.arrayGlue) {
+		return str
+	}
+	parts := strings.Split(str, *c.arrayGlue)
+	items := make([]interface{}, len(parts))
+	for i, part := range parts {
+		items[i] = part
+	}
+	return items
+}
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *ExportCommand) Synopsis() string {
+	c.init()
+	return c.synopsis
+}
+
+func (c *ExportCommand) Help() string {
+	c.init()
+	flagOut := new(bytes.Buffer)
+	c.flags.SetOutput(flagOut)
+	c.flags.PrintDefaults()
+	c.flags.SetOutput(nil)
+	return fmt.Sprintf("%s %s\n\n%s\n\nOptions:\n%s", c.name, c.args, c.synopsis, flagOut.String())
+}
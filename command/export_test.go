@@ -0,0 +1,55 @@
+package command
+
+import "testing"
+
+func newTestExportCommand(splitArrays bool, glue string) *ExportCommand {
+	return &ExportCommand{splitArrays: &splitArrays, arrayGlue: &glue}
+}
+
+func TestToTree(t *testing.T) {
+	t.Run("nests keys by path segment", func(t *testing.T) {
+		c := newTestExportCommand(false, "\n")
+		tree, err := c.toTree(map[string][]byte{"a/b": []byte("1"), "a/c": []byte("2")})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		a, ok := tree["a"].(map[string]interface{})
+		if !ok || a["b"] != "1" || a["c"] != "2" {
+			t.Fatalf("got %#v, want a: {b: 1, c: 2}", tree)
+		}
+	})
+
+	t.Run("errors instead of dropping a scalar shadowed by a deeper key", func(t *testing.T) {
+		c := newTestExportCommand(false, "\n")
+		_, err := c.toTree(map[string][]byte{"a": []byte("scalarvalue"), "a/b": []byte("nestedvalue")})
+		if err == nil {
+			t.Fatal("expected an error for the colliding \"a\" / \"a/b\" keys, got nil")
+		}
+	})
+
+	t.Run("errors instead of dropping a scalar shadowed at a deeper level", func(t *testing.T) {
+		c := newTestExportCommand(false, "\n")
+		_, err := c.toTree(map[string][]byte{"a/b": []byte("bvalue"), "a/b/c": []byte("cvalue")})
+		if err == nil {
+			t.Fatal("expected an error for the colliding \"a/b\" / \"a/b/c\" keys, got nil")
+		}
+	})
+}
+
+func TestToValue(t *testing.T) {
+	t.Run("leaves a plain scalar untouched by default", func(t *testing.T) {
+		c := newTestExportCommand(false, "\n")
+		got := c.toValue([]byte("line one\nline two"))
+		if got != "line one\nline two" {
+			t.Fatalf("got %#v, want the original string", got)
+		}
+	})
+
+	t.Run("splits into a sequence only when -split-arrays is set", func(t *testing.T) {
+		c := newTestExportCommand(true, "\n")
+		got, ok := c.toValue([]byte("one\ntwo")).([]interface{})
+		if !ok || len(got) != 2 || got[0] != "one" || got[1] != "two" {
+			t.Fatalf("got %#v, want [\"one\" \"two\"]", got)
+		}
+	})
+}
@@ -2,32 +2,42 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"strings"
 
 	"github.com/lewispeckover/consulator/command/configparser"
 
-	"github.com/hashicorp/consul/api"
 	"github.com/mitchellh/cli"
 	"sort"
 )
 
 type ImportCommand struct {
-	Ui          cli.Ui
-	name        string
-	args        string
-	synopsis    string
-	flags       *flag.FlagSet
-	parseAsYAML *bool
-	parseAsJSON *bool
-	parseAsTAR  *bool
-	dryMode     *bool
-	verbose     *bool
-	arrayGlue   *string
-	keyPrefix   *string
-	initialised bool
-	Purge       bool
+	Ui           cli.Ui
+	name         string
+	args         string
+	synopsis     string
+	flags        *flag.FlagSet
+	parseAsYAML  *bool
+	parseAsJSON  *bool
+	parseAsTAR   *bool
+	dryMode      *bool
+	verbose      *bool
+	arrayGlue    *string
+	keyPrefix    *string
+	backendName  *string
+	consul       consulFlags
+	etcd         etcdFlags
+	casMode      *bool
+	templateMode *bool
+	templateVars templateVarFlag
+	templateEnv  *bool
+	renderOnly   *bool
+	outputFormat *string
+	exitCode     *bool
+	initialised  bool
+	Purge        bool
 }
 
 func (c *ImportCommand) init() {
@@ -50,6 +60,17 @@ func (c *ImportCommand) init() {
 	c.verbose = c.flags.Bool("verbose", false, "Output more detailed information")
 	c.arrayGlue = c.flags.String("glue", "\n", "Glue to use for joining array values")
 	c.keyPrefix = c.flags.String("prefix", "", "Consul tree to work under")
+	c.backendName = c.flags.String("backend", "consul", "KV backend to sync to: consul or etcd")
+	c.consul.register(c.flags)
+	c.etcd.register(c.flags)
+	c.casMode = c.flags.Bool("cas", false, "Use check-and-set on every write so a concurrent writer aborts the sync instead of being clobbered")
+	c.templateMode = c.flags.Bool("template", false, "Render input files as Go templates before parsing")
+	c.templateVars = make(templateVarFlag)
+	c.flags.Var(c.templateVars, "template-var", "Template variable in key=value form (repeatable)")
+	c.templateEnv = c.flags.Bool("template-env", false, "Expose the process environment to the template env function")
+	c.renderOnly = c.flags.Bool("render-only", false, "Print rendered template output to stdout and exit, without touching Consul")
+	c.outputFormat = c.flags.String("output", "text", "Diff report format: text, json, or diff")
+	c.exitCode = c.flags.Bool("exit-code", false, "With -dry, exit 2 if any change would be made, mirroring terraform plan -detailed-exitcode")
 	c.flags.Usage = func() { c.Ui.Output(c.Help()) }
 	c.initialised = true
 }
@@ -63,11 +84,38 @@ func (c *ImportCommand) Run(args []string) int {
 		c.Ui.Error("Only one input format may be specified")
 		return 1
 	}
+	if err := c.consul.validate(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error: %s", err))
+		return 1
+	}
+	switch *c.outputFormat {
+	case "text", "json", "diff":
+	default:
+		c.Ui.Error(fmt.Sprintf("Invalid -output %q: must be text, json, or diff", *c.outputFormat))
+		return 1
+	}
 	// clean up the prefix
 	*c.keyPrefix = strings.TrimSuffix(strings.TrimSpace(*c.keyPrefix), "/")
 	if *c.keyPrefix != "" {
 		*c.keyPrefix = *c.keyPrefix + "/"
 	}
+
+	if *c.renderOnly {
+		if c.flags.NArg() == 0 {
+			c.Ui.Error("-render-only requires at least one input file")
+			return 1
+		}
+		for _, p := range c.flags.Args() {
+			rendered, err := c.renderTemplate(p)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error: %s", err))
+				return 1
+			}
+			c.Ui.Output(string(rendered))
+		}
+		return 0
+	}
+
 	data := make(map[string][]byte)
 	if c.flags.NArg() == 0 {
 		switch {
@@ -94,38 +142,91 @@ func (c *ImportCommand) Run(args []string) int {
 		}
 	} else {
 		for _, p := range c.flags.Args() {
-			if err := configparser.Parse(p, data, *c.arrayGlue); err != nil {
+			parsePath := p
+			if *c.templateMode {
+				renderedPath, cleanup, err := c.renderTemplateToFile(p)
+				if err != nil {
+					c.Ui.Error(fmt.Sprintf("Error: %s", err))
+					return 1
+				}
+				defer cleanup()
+				parsePath = renderedPath
+			}
+			if err := configparser.Parse(parsePath, data, *c.arrayGlue); err != nil {
 				c.Ui.Error(fmt.Sprintf("Error: %s", err))
 				return 1
 			}
 		}
 	}
-	if err := c.syncConsul(data); err != nil {
+	report, err := c.sync(data)
+	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error: %s", err))
 		return 1
 	}
+	c.reportDiff(report)
+
+	if *c.exitCode && *c.dryMode && report.hasChanges() {
+		return 2
+	}
 	return 0
 }
 
-func (c *ImportCommand) syncConsul(data map[string][]byte) error {
-	config := api.DefaultConfig()
-	client, err := api.NewClient(config)
+// reportDiff renders the diff report in the requested -output format.
+func (c *ImportCommand) reportDiff(report *diffReport) {
+	switch *c.outputFormat {
+	case "json":
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error: %s", err))
+			return
+		}
+		c.Ui.Output(string(out))
+	case "diff":
+		for _, key := range report.Deletes {
+			c.Ui.Output(unifiedDiff(key, report.deleteValues[key], ""))
+		}
+		for _, update := range report.Updates {
+			c.Ui.Output(unifiedDiff(update.Key, update.Old, update.New))
+		}
+		for _, key := range report.Inserts {
+			c.Ui.Output(unifiedDiff(key, "", report.insertValues[key]))
+		}
+	default:
+		if c.Purge {
+			c.Ui.Output(fmt.Sprintf("Sync completed. %d keys deleted, %d key inserted, %d keys updated.", len(report.Deletes), len(report.Inserts), len(report.Updates)))
+		} else {
+			c.Ui.Output(fmt.Sprintf("Import completed. %d keys set.", len(report.Inserts)+len(report.Updates)))
+		}
+	}
+}
+
+// backend builds the KVBackend selected by -backend.
+func (c *ImportCommand) backend() (KVBackend, error) {
+	switch *c.backendName {
+	case "consul", "":
+		return newConsulBackend(&c.consul)
+	case "etcd":
+		return newEtcdBackend(&c.etcd)
+	default:
+		return nil, fmt.Errorf("unknown -backend %q: must be consul or etcd", *c.backendName)
+	}
+}
 
+// sync diffs data against the remote KV tree and applies the result. The
+// diffing and reporting below are backend-agnostic; only the backend()
+// construction and the List and Txn calls on it talk to a specific KV store.
+func (c *ImportCommand) sync(data map[string][]byte) (*diffReport, error) {
+	backend, err := c.backend()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	kv := client.KV()
-
-	// Initialize statistics
-	deleted := 0
-	inserted := 0
-	updated := 0
+	report := &diffReport{insertValues: map[string]string{}, deleteValues: map[string]string{}}
 
 	// Get remote key value pairs
-	pairs, _, err := kv.List(*c.keyPrefix, &api.QueryOptions{})
+	pairs, err := backend.List(*c.keyPrefix)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Sort remote key value pairs
@@ -134,11 +235,13 @@ func (c *ImportCommand) syncConsul(data map[string][]byte) error {
 	})
 
 	// Index remote key value pairs
-	indexedPairs := make(map[string]*api.KVPair, len(pairs))
+	indexedPairs := make(map[string]Pair, len(pairs))
 	for _, pair := range pairs {
 		indexedPairs[pair.Key] = pair
 	}
 
+	var ops []TxnOp
+
 	// Enumerate remote key value pairs
 	for _, pair := range pairs {
 		// if there was a prefix, we need to strip it
@@ -151,23 +254,22 @@ func (c *ImportCommand) syncConsul(data map[string][]byte) error {
 
 				// Remove from local key value pairs since no change is required
 				delete(data, relativeKey)
+				report.Unchanged++
 			}
 		} else if c.Purge {
 			// Remote key does not exist in local key value pairs
 
 			// Delete in sync mode
 			if !*c.dryMode {
-				_, err := kv.Delete(pair.Key, nil)
-				if err != nil {
-					return err
-				}
+				ops = append(ops, c.toDeleteOp(pair))
 			}
 
-			if *c.verbose {
+			if *c.verbose && *c.outputFormat == "text" {
 				c.Ui.Output(fmt.Sprintf("Delete key \"%s\" with value \"%s\"", pair.Key, string(pair.Value)))
 			}
 
-			deleted++
+			report.Deletes = append(report.Deletes, pair.Key)
+			report.deleteValues[pair.Key] = string(pair.Value)
 		}
 	}
 
@@ -181,47 +283,83 @@ func (c *ImportCommand) syncConsul(data map[string][]byte) error {
 	// Enumerate local key value pairs
 	for _, key := range dataKeys {
 		val := data[key]
+		absoluteKey := *c.keyPrefix + key
+		pair, exists := indexedPairs[absoluteKey]
 
 		if !*c.dryMode {
-			_, err := kv.Put(c.toKVPair(key, val), nil)
-			if err != nil {
-				return err
-			}
+			ops = append(ops, c.toPutOp(key, val, pair, exists))
 		}
 
-		absoluteKey := *c.keyPrefix + key
-		if pair, ok := indexedPairs[absoluteKey]; ok {
+		if exists {
 			// Local key exists in remote keys (Update)
 
-			if *c.verbose {
+			if *c.verbose && *c.outputFormat == "text" {
 				c.Ui.Output(fmt.Sprintf("Update key %s with value \"%s\" from previous value \"%s\"", key, string(val), string(pair.Value)))
 			}
 
-			updated++
+			report.Updates = append(report.Updates, diffUpdate{Key: absoluteKey, Old: string(pair.Value), New: string(val)})
 		} else {
 			// Local key does not exist in remote keys (Insert)
-			if *c.verbose {
+			if *c.verbose && *c.outputFormat == "text" {
 				c.Ui.Output(fmt.Sprintf("Insert key %s with value \"%s\"", key, string(val)))
 			}
 
-			inserted++
+			report.Inserts = append(report.Inserts, absoluteKey)
+			report.insertValues[absoluteKey] = string(val)
 		}
 	}
 
-	if c.Purge {
-		c.Ui.Output(fmt.Sprintf("Sync completed. %d keys deleted, %d key inserted, %d keys updated.", deleted, inserted, updated))
-	} else {
-		c.Ui.Output(fmt.Sprintf("Import completed. %d keys set.", inserted + updated))
+	if len(ops) > 0 {
+		if err := c.applyTxnOps(backend, ops); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// toDeleteOp builds the TxnOp used to remove a purged key, using TxnDeleteCAS
+// against the pair's Index when -cas is set so a concurrent writer aborts
+// the transaction instead of being silently clobbered.
+func (c *ImportCommand) toDeleteOp(pair Pair) TxnOp {
+	verb := TxnDelete
+	index := uint64(0)
+	if *c.casMode {
+		verb = TxnDeleteCAS
+		index = pair.Index
 	}
-	return nil
+	return TxnOp{Verb: verb, Key: pair.Key, Index: index}
 }
 
-func (c *ImportCommand) toKVPair(key string, val []byte) *api.KVPair {
-	return &api.KVPair{
-		Key:   *c.keyPrefix + key,
-		Flags: 0,
-		Value: val,
+// toPutOp builds the TxnOp used to insert or update a key, using TxnCAS
+// against the remote pair's Index (or 0 for a key that must not yet exist)
+// when -cas is set.
+func (c *ImportCommand) toPutOp(key string, val []byte, existing Pair, exists bool) TxnOp {
+	verb := TxnSet
+	index := uint64(0)
+	if *c.casMode {
+		verb = TxnCAS
+		if exists {
+			index = existing.Index
+		}
+	}
+	return TxnOp{Verb: verb, Key: *c.keyPrefix + key, Value: val, Index: index}
+}
+
+// applyTxnOps hands ops to the backend and, on a CAS conflict, reports the
+// affected key(s), including the backend's reason when it provides one.
+func (c *ImportCommand) applyTxnOps(backend KVBackend, ops []TxnOp) error {
+	err := backend.Txn(ops)
+	if failed, ok := err.(*TxnFailedKeysError); ok {
+		for i, key := range failed.Keys {
+			if reason := failed.reasonFor(i); reason != "" {
+				c.Ui.Error(fmt.Sprintf("Transaction failed for key \"%s\": %s", key, reason))
+			} else {
+				c.Ui.Error(fmt.Sprintf("Transaction failed for key \"%s\"", key))
+			}
+		}
 	}
+	return err
 }
 
 func (c *ImportCommand) Synopsis() string {
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestImportCommand(cas bool, prefix string) *ImportCommand {
+	return &ImportCommand{casMode: &cas, keyPrefix: &prefix}
+}
+
+func TestToPutOp(t *testing.T) {
+	t.Run("cas disabled always uses TxnSet with a zero index", func(t *testing.T) {
+		c := newTestImportCommand(false, "app/")
+		op := c.toPutOp("key", []byte("val"), Pair{Index: 42}, true)
+		if op.Verb != TxnSet || op.Index != 0 {
+			t.Fatalf("got %+v, want TxnSet with index 0", op)
+		}
+	})
+
+	t.Run("cas enabled and key exists uses the remote index", func(t *testing.T) {
+		c := newTestImportCommand(true, "app/")
+		op := c.toPutOp("key", []byte("val"), Pair{Index: 42}, true)
+		if op.Verb != TxnCAS || op.Index != 42 {
+			t.Fatalf("got %+v, want TxnCAS with index 42", op)
+		}
+	})
+
+	t.Run("cas enabled and key does not exist requires index 0", func(t *testing.T) {
+		c := newTestImportCommand(true, "app/")
+		op := c.toPutOp("key", []byte("val"), Pair{Index: 42}, false)
+		if op.Verb != TxnCAS || op.Index != 0 {
+			t.Fatalf("got %+v, want TxnCAS with index 0", op)
+		}
+	})
+
+	t.Run("key is prefixed and value preserved", func(t *testing.T) {
+		c := newTestImportCommand(false, "app/")
+		op := c.toPutOp("key", []byte("val"), Pair{}, false)
+		if op.Key != "app/key" || !bytes.Equal(op.Value, []byte("val")) {
+			t.Fatalf("got %+v, want key \"app/key\" with value \"val\"", op)
+		}
+	})
+}
+
+func TestToDeleteOp(t *testing.T) {
+	t.Run("cas disabled uses TxnDelete with a zero index", func(t *testing.T) {
+		c := newTestImportCommand(false, "app/")
+		op := c.toDeleteOp(Pair{Key: "app/key", Index: 42})
+		if op.Verb != TxnDelete || op.Index != 0 {
+			t.Fatalf("got %+v, want TxnDelete with index 0", op)
+		}
+	})
+
+	t.Run("cas enabled uses TxnDeleteCAS against the remote index", func(t *testing.T) {
+		c := newTestImportCommand(true, "app/")
+		op := c.toDeleteOp(Pair{Key: "app/key", Index: 42})
+		if op.Verb != TxnDeleteCAS || op.Index != 42 {
+			t.Fatalf("got %+v, want TxnDeleteCAS with index 42", op)
+		}
+	})
+}
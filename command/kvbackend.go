@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxTxnOps caps the number of operations a backend applies in a single
+// atomic transaction. It matches Consul's hard /v1/txn limit; etcd backends
+// reuse it as a conservative default.
+const maxTxnOps = 64
+
+// Pair is a single KV entry, backend-agnostic.
+type Pair struct {
+	Key   string
+	Value []byte
+	// Index is the backend's native version/revision for the key
+	// (Consul's ModifyIndex, etcd's ModRevision), used for CAS ops.
+	Index uint64
+}
+
+// TxnVerb is the kind of change a TxnOp makes.
+type TxnVerb int
+
+const (
+	TxnSet TxnVerb = iota
+	TxnDelete
+	TxnCAS
+	TxnDeleteCAS
+)
+
+// TxnOp is one change to apply as part of a backend transaction. For the CAS
+// verbs, Index must match the key's current Index (or be 0 if the key must
+// not yet exist) or the whole transaction is rejected.
+type TxnOp struct {
+	Verb  TxnVerb
+	Key   string
+	Value []byte
+	Index uint64
+}
+
+// KVBackend is the minimal KV store operations consulator needs to sync a
+// config tree. Consul and etcd both implement it.
+type KVBackend interface {
+	List(prefix string) ([]Pair, error)
+	Put(pair Pair) error
+	Delete(key string) error
+	Txn(ops []TxnOp) error
+}
+
+// TxnFailedKeysError is returned by a KVBackend's Txn when one or more
+// batches were rejected, carrying the keys involved so the caller can report
+// them without needing to know which backend rejected the transaction.
+// Reasons holds the backend's explanation for the failure of the key at the
+// same index, when the backend can provide one; it may be shorter than Keys,
+// or contain empty strings, for backends that can't.
+type TxnFailedKeysError struct {
+	Keys    []string
+	Reasons []string
+}
+
+// reasonFor returns the reason reported for the i'th key, or "" if the
+// backend didn't provide one.
+func (e *TxnFailedKeysError) reasonFor(i int) string {
+	if i < len(e.Reasons) {
+		return e.Reasons[i]
+	}
+	return ""
+}
+
+func (e *TxnFailedKeysError) Error() string {
+	return fmt.Sprintf("transaction failed for %d key(s): %s", len(e.Keys), strings.Join(e.Keys, ", "))
+}
+
+// batchOps splits ops into consecutive batches of at most size ops each, the
+// chunking both consulBackend.Txn and etcdBackend.Txn apply before submitting
+// to their respective transaction APIs.
+func batchOps(ops []TxnOp, size int) [][]TxnOp {
+	if len(ops) == 0 {
+		return nil
+	}
+	batches := make([][]TxnOp, 0, (len(ops)+size-1)/size)
+	for start := 0; start < len(ops); start += size {
+		end := start + size
+		if end > len(ops) {
+			end = len(ops)
+		}
+		batches = append(batches, ops[start:end])
+	}
+	return batches
+}
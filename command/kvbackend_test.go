@@ -0,0 +1,44 @@
+package command
+
+import "testing"
+
+func TestBatchOps(t *testing.T) {
+	makeOps := func(n int) []TxnOp {
+		ops := make([]TxnOp, n)
+		for i := range ops {
+			ops[i] = TxnOp{Key: string(rune('a' + i))}
+		}
+		return ops
+	}
+
+	cases := []struct {
+		name     string
+		n        int
+		size     int
+		wantLens []int
+	}{
+		{"empty", 0, 4, nil},
+		{"single short batch", 3, 4, []int{3}},
+		{"exact multiple", 8, 4, []int{4, 4}},
+		{"one over a multiple", 9, 4, []int{4, 4, 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := batchOps(makeOps(tc.n), tc.size)
+			if len(batches) != len(tc.wantLens) {
+				t.Fatalf("got %d batches, want %d", len(batches), len(tc.wantLens))
+			}
+			total := 0
+			for i, batch := range batches {
+				if len(batch) != tc.wantLens[i] {
+					t.Errorf("batch %d: got %d ops, want %d", i, len(batch), tc.wantLens[i])
+				}
+				total += len(batch)
+			}
+			if total != tc.n {
+				t.Errorf("batches cover %d ops, want %d", total, tc.n)
+			}
+		})
+	}
+}
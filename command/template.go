@@ -0,0 +1,126 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// templateVarFlag collects repeatable -template-var key=value flags into a
+// map that is exposed to templates as the root data value.
+type templateVarFlag map[string]string
+
+func (f templateVarFlag) String() string {
+	return fmt.Sprintf("%v", map[string]string(f))
+}
+
+func (f templateVarFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("template variable %q must be in key=value form", value)
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+// renderTemplate reads path and runs it through text/template, returning the
+// rendered bytes. The template name is set to path so parse and execution
+// errors carry file:line context.
+func (c *ImportCommand) renderTemplate(path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(path).Funcs(c.templateFuncMap()).Parse(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, map[string]string(c.templateVars)); err != nil {
+		return nil, err
+	}
+	return []byte(out.String()), nil
+}
+
+// renderTemplateToFile renders path and writes the result to a temporary
+// file with the same extension, so configparser.Parse can still detect the
+// input format by name. The returned cleanup func removes the temp file and
+// must be called once the caller is done with it.
+func (c *ImportCommand) renderTemplateToFile(path string) (renderedPath string, cleanup func(), err error) {
+	rendered, err := c.renderTemplate(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tmp, err := ioutil.TempFile("", "consulator-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+
+	if _, err := tmp.Write(rendered); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
+// templateFuncMap builds the helper functions available to input file
+// templates, borrowing the consul-template naming convention.
+func (c *ImportCommand) templateFuncMap() template.FuncMap {
+	return template.FuncMap{
+		"env": func(key string) (string, error) {
+			if !*c.templateEnv {
+				return "", fmt.Errorf("env %q: the env function requires -template-env", key)
+			}
+			return os.Getenv(key), nil
+		},
+		"file": func(path string) (string, error) {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return string(content), nil
+		},
+		"default": func(def, val string) string {
+			if val == "" {
+				return def
+			}
+			return val
+		},
+		"toYaml": func(v interface{}) (string, error) {
+			out, err := yaml.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(string(out), "\n"), nil
+		},
+		"indent": func(spaces int, s string) string {
+			pad := strings.Repeat(" ", spaces)
+			lines := strings.Split(s, "\n")
+			for i, line := range lines {
+				lines[i] = pad + line
+			}
+			return strings.Join(lines, "\n")
+		},
+		"required": func(val string) (string, error) {
+			if val == "" {
+				return "", fmt.Errorf("a required template value was empty")
+			}
+			return val, nil
+		},
+	}
+}
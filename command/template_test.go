@@ -0,0 +1,55 @@
+package command
+
+import "testing"
+
+func TestTemplateFuncMap(t *testing.T) {
+	templateEnv := false
+	c := &ImportCommand{templateEnv: &templateEnv}
+	fm := c.templateFuncMap()
+
+	t.Run("default falls back when the value is empty", func(t *testing.T) {
+		def := fm["default"].(func(string, string) string)
+		if got := def("fallback", ""); got != "fallback" {
+			t.Errorf("got %q, want %q", got, "fallback")
+		}
+		if got := def("fallback", "value"); got != "value" {
+			t.Errorf("got %q, want %q", got, "value")
+		}
+	})
+
+	t.Run("indent pads every line", func(t *testing.T) {
+		indent := fm["indent"].(func(int, string) string)
+		if got := indent(2, "a\nb"); got != "  a\n  b" {
+			t.Errorf("got %q, want %q", got, "  a\n  b")
+		}
+	})
+
+	t.Run("toYaml marshals a value without a trailing newline", func(t *testing.T) {
+		toYaml := fm["toYaml"].(func(interface{}) (string, error))
+		got, err := toYaml(map[string]string{"key": "value"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != "key: value" {
+			t.Errorf("got %q, want %q", got, "key: value")
+		}
+	})
+
+	t.Run("required errors on an empty value", func(t *testing.T) {
+		required := fm["required"].(func(string) (string, error))
+		if _, err := required(""); err == nil {
+			t.Error("expected an error for an empty value")
+		}
+		got, err := required("value")
+		if err != nil || got != "value" {
+			t.Errorf("got (%q, %v), want (%q, nil)", got, err, "value")
+		}
+	})
+
+	t.Run("env errors without -template-env", func(t *testing.T) {
+		env := fm["env"].(func(string) (string, error))
+		if _, err := env("HOME"); err == nil {
+			t.Error("expected an error when -template-env is not set")
+		}
+	})
+}